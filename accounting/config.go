@@ -0,0 +1,58 @@
+package accounting
+
+import (
+	"context"
+	"time"
+
+	"github.com/lightninglabs/lndclient"
+)
+
+// OffChainConfig contains all the functionality required to produce an off
+// chain report.
+type OffChainConfig struct {
+	// StreamInvoices delivers our node's invoices on the returned
+	// channel, paginating its requests to lnd internally in batches of
+	// BatchSize. The error channel carries at most one error, and is
+	// closed (with the invoice channel) once all invoices have been
+	// delivered or an error is hit.
+	StreamInvoices func(ctx context.Context) (<-chan lndclient.Invoice,
+		<-chan error)
+
+	// StreamPayments delivers our node's payments on the returned
+	// channel, paginating its requests to lnd internally in batches of
+	// BatchSize. The error channel carries at most one error, and is
+	// closed (with the payment channel) once all payments have been
+	// delivered or an error is hit.
+	StreamPayments func(ctx context.Context) (<-chan lndclient.Payment,
+		<-chan error)
+
+	// ListForwards lists the set of forwards over our relevant period.
+	ListForwards func() ([]lndclient.ForwardingEvent, error)
+
+	// BatchSize is the number of invoices/payments that StreamInvoices/
+	// StreamPayments should request from lnd per page. It is surfaced
+	// here (rather than being a hardcoded constant) so that callers can
+	// tune it for their node's history size and lnd's response latency.
+	BatchSize uint64
+
+	// OwnPubKey is our node's public key. We need this value to identify
+	// payments that are made to our own node.
+	OwnPubKey string
+
+	// StartTime is the time from which the report should be created,
+	// inclusive.
+	StartTime time.Time
+
+	// EndTime is the time until which the report should be created,
+	// exclusive.
+	EndTime time.Time
+
+	// GranularInvoiceHTLCs determines how settled invoices are reported.
+	// When false (the default), a single entry is produced per invoice,
+	// priced at the invoice's settle time - this is faraday's historical
+	// behavior. When true, a separate entry is produced for each settled
+	// htlc, priced at that htlc's own resolve time, which gives more
+	// accurate results for AMP/MPP invoices whose htlcs can settle at
+	// different times.
+	GranularInvoiceHTLCs bool
+}
@@ -0,0 +1,97 @@
+package accounting
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/lnwire"
+)
+
+// invoiceEntry produces the set of harmony entries for a settled invoice. By
+// default we emit a single entry priced at the invoice's settle time, which
+// matches faraday's historical behavior. When granular is set (the caller
+// passes cfg.GranularInvoiceHTLCs) and the invoice has htlc records, we
+// instead emit one entry per settled htlc, each priced at that htlc's own
+// resolve time.
+//
+// The granular mode exists because AMP/MPP invoices are settled by multiple
+// htlcs that can arrive and resolve at different times, sometimes minutes
+// apart. Collapsing them into a single invoice-level entry hides that an
+// invoice's value may have actually been earned at several different fiat
+// prices, which matters for accurate tax/accounting reports.
+func invoiceEntry(invoice lndclient.Invoice, toSelf, granular bool,
+	convert msatToFiat) ([]*HarmonyEntry, error) {
+
+	if !granular || len(invoice.Htlcs) == 0 {
+		entry, err := invoiceSettleEntry(
+			invoice, invoice.SettleDate, invoice.AmountPaid,
+			toSelf, convert,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		return []*HarmonyEntry{entry}, nil
+	}
+
+	var entries []*HarmonyEntry
+
+	for _, htlc := range invoice.Htlcs {
+		if htlc.State != lnrpc.InvoiceHTLCState_SETTLED {
+			continue
+		}
+
+		entry, err := invoiceSettleEntry(
+			invoice, htlc.ResolveTime, htlc.Amt, toSelf, convert,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		// Distinguish per-htlc entries from one another using their
+		// circuit key, since they otherwise share a payment hash.
+		entry.Reference = fmt.Sprintf(
+			"%v:%v", entry.Reference, htlc.CircuitKey,
+		)
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// invoiceSettleEntry creates a single harmony entry for a settled invoice (or
+// one of its htlcs), priced at settleTime using the amount provided.
+func invoiceSettleEntry(invoice lndclient.Invoice, settleTime time.Time,
+	amount lnwire.MilliSatoshi, toSelf bool,
+	convert msatToFiat) (*HarmonyEntry, error) {
+
+	fiat, err := convert(amount, settleTime)
+	if err != nil {
+		return nil, err
+	}
+
+	note := ""
+	if toSelf {
+		note = "paid to self"
+	}
+
+	entryType := EntryTypeReceipt
+	if toSelf {
+		entryType = EntryTypePaymentToSelf
+	}
+
+	return &HarmonyEntry{
+		Timestamp: settleTime,
+		Amount:    amount,
+		FiatValue: fiat,
+		TxID:      invoice.Hash.String(),
+		Reference: invoice.Hash.String(),
+		Note:      note,
+		Type:      entryType,
+		OnChain:   false,
+		Credit:    true,
+	}, nil
+}
@@ -0,0 +1,109 @@
+package accounting
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/lntypes"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/shopspring/decimal"
+)
+
+// TestInvoiceEntry tests that invoiceEntry produces a single aggregated
+// entry by default, and one entry per settled htlc when granular reporting
+// is requested.
+func TestInvoiceEntry(t *testing.T) {
+	var (
+		hash       = lntypes.Hash{1, 2, 3}
+		settleTime = time.Unix(1000, 0)
+		htlc1Time  = time.Unix(1001, 0)
+		htlc2Time  = time.Unix(1002, 0)
+	)
+
+	invoice := lndclient.Invoice{
+		Hash:       hash,
+		SettleDate: settleTime,
+		AmountPaid: lnwire.MilliSatoshi(300),
+		Htlcs: []lndclient.InvoiceHtlc{
+			{
+				State:       lnrpc.InvoiceHTLCState_SETTLED,
+				Amt:         lnwire.MilliSatoshi(100),
+				ResolveTime: htlc1Time,
+			},
+			{
+				State:       lnrpc.InvoiceHTLCState_SETTLED,
+				Amt:         lnwire.MilliSatoshi(200),
+				ResolveTime: htlc2Time,
+			},
+			{
+				// Cancelled htlcs must not produce an entry,
+				// even in granular mode.
+				State:       lnrpc.InvoiceHTLCState_CANCELED,
+				Amt:         lnwire.MilliSatoshi(50),
+				ResolveTime: htlc2Time,
+			},
+		},
+	}
+
+	convert := func(amt lnwire.MilliSatoshi,
+		_ time.Time) (decimal.Decimal, error) {
+
+		return decimal.NewFromInt(int64(amt)), nil
+	}
+
+	tests := []struct {
+		name      string
+		granular  bool
+		wantTimes []time.Time
+		wantAmts  []lnwire.MilliSatoshi
+	}{
+		{
+			name:      "aggregate",
+			granular:  false,
+			wantTimes: []time.Time{settleTime},
+			wantAmts:  []lnwire.MilliSatoshi{300},
+		},
+		{
+			name:      "granular",
+			granular:  true,
+			wantTimes: []time.Time{htlc1Time, htlc2Time},
+			wantAmts:  []lnwire.MilliSatoshi{100, 200},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			entries, err := invoiceEntry(
+				invoice, false, test.granular, convert,
+			)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(entries) != len(test.wantTimes) {
+				t.Fatalf("expected: %v entries, got: %v",
+					len(test.wantTimes), len(entries))
+			}
+
+			for i, entry := range entries {
+				if !entry.Timestamp.Equal(test.wantTimes[i]) {
+					t.Errorf("entry %v: expected timestamp "+
+						"%v, got %v", i, test.wantTimes[i],
+						entry.Timestamp)
+				}
+
+				if entry.Amount != test.wantAmts[i] {
+					t.Errorf("entry %v: expected amount %v, "+
+						"got %v", i, test.wantAmts[i],
+						entry.Amount)
+				}
+			}
+		})
+	}
+}
@@ -38,37 +38,38 @@ func OffChainReport(ctx context.Context, cfg *OffChainConfig) (Report, error) {
 		return nil, err
 	}
 
-	return offChainReportWithPrices(cfg, getPrice)
+	return offChainReportWithPrices(ctx, cfg, getPrice)
 }
 
 // offChainReportWithPrices produces off chain reports using the getPrice
-// function provided. This allows testing of our report creation without calling
-// the actual price API.
-func offChainReportWithPrices(cfg *OffChainConfig, getPrice msatToFiat) (Report,
-	error) {
-
-	invoices, err := cfg.ListInvoices()
-	if err != nil {
-		return nil, err
-	}
-	filteredInvoices := filterInvoices(cfg.StartTime, cfg.EndTime, invoices)
-
-	payments, err := cfg.ListPayments()
+// function provided. This allows testing of our report creation without
+// calling the actual price API.
+//
+// Invoices and payments are consumed from cfg's streaming hooks rather than
+// being loaded into memory as a single slice, so that nodes with very large
+// payment/invoice histories do not OOM generating a report. We stream
+// payments to completion before invoices, because identifying which
+// invoices were paid to our own node requires the full set of payments that
+// our node made to itself - an invoice and the payment that settles it can
+// arrive from lnd in either order, so we cannot emit invoice entries until
+// every payment has been examined.
+func offChainReportWithPrices(ctx context.Context, cfg *OffChainConfig,
+	getPrice msatToFiat) (Report, error) {
+
+	circularPayments, paymentEntries, err := streamPayments(
+		ctx, cfg, getPrice,
+	)
 	if err != nil {
 		return nil, err
 	}
 
-	// Get a list of all the payments we made to ourselves.
-	paymentsToSelf, err := getCircularPayments(cfg.OwnPubKey, payments)
+	invoiceEntries, err := streamInvoices(
+		ctx, cfg, circularPayments, getPrice,
+	)
 	if err != nil {
 		return nil, err
 	}
 
-	filteredPayments := filterPayments(cfg.StartTime, cfg.EndTime, payments)
-	if err := sanityCheckDuplicates(filteredPayments); err != nil {
-		return nil, err
-	}
-
 	// Get all our forwards, we do not need to filter them because they
 	// are already supplied over the relevant range for our query.
 	forwards, err := cfg.ListForwards()
@@ -76,134 +77,162 @@ func offChainReportWithPrices(cfg *OffChainConfig, getPrice msatToFiat) (Report,
 		return nil, err
 	}
 
-	return offChainReport(
-		filteredInvoices, filteredPayments, paymentsToSelf, forwards,
-		getPrice,
-	)
-}
-
-// offChainReport produces an off chain transaction report. This function
-// assumes that all entries passed into this function fall within our target
-// date range, with the exception of payments to self which tracks payments
-// that were made to ourselves for the sake of appropriately reporting the
-// invoices they paid.
-
-func offChainReport(invoices []lndclient.Invoice, payments []settledPayment,
-	circularPayments map[string]bool, forwards []lndclient.ForwardingEvent,
-	convert msatToFiat) (Report, error) {
-
 	var reports Report
+	reports = append(reports, invoiceEntries...)
+	reports = append(reports, paymentEntries...)
 
-	for _, invoice := range invoices {
-		// If the invoice's payment hash is in our set of circular
-		// payments, we know that this payment was made to ourselves.
-		toSelf := circularPayments[invoice.Hash.String()]
-
-		entry, err := invoiceEntry(invoice, toSelf, convert)
+	for _, forward := range forwards {
+		entries, err := forwardingEntry(forward, getPrice)
 		if err != nil {
 			return nil, err
 		}
 
-		reports = append(reports, entry)
+		reports = append(reports, entries...)
 	}
 
-	for _, payment := range payments {
-		// If the payment's payment request is in our set of circular
-		// payments, we know that this payment was made to ourselves.
-		toSelf := circularPayments[payment.Hash.String()]
+	return reports, nil
+}
+
+// streamPayments consumes cfg.StreamPayments to completion in a single pass,
+// building the map of payments that were made to our own node (needed to
+// label self-paid invoices) and the report entries for payments that settled
+// within [cfg.StartTime, cfg.EndTime), without ever holding the full payment
+// history in memory at once.
+func streamPayments(ctx context.Context, cfg *OffChainConfig,
+	convert msatToFiat) (map[string]bool, Report, error) {
+
+	paymentChan, errChan := cfg.StreamPayments(ctx)
 
-		entries, err := paymentEntry(payment, toSelf, convert)
+	circularPayments := make(map[string]bool)
+	uniqueHashes := make(map[lntypes.Hash]bool)
+	var entries Report
+
+	for payment := range paymentChan {
+		toSelf, err := getCircularPayment(cfg.OwnPubKey, payment)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
-		reports = append(reports, entries...)
-	}
+		// Before we record our entry, we sanity check that if this
+		// payment hash has duplicates (allowed for legacy nodes), the
+		// value we have recorded for it so far is the same as the
+		// value we are about to add.
+		duplicateToSelf, ok := circularPayments[payment.Hash.String()]
+		if ok && duplicateToSelf != toSelf {
+			return nil, nil, errDifferentDuplicates
+		}
+		if toSelf {
+			circularPayments[payment.Hash.String()] = toSelf
+		}
 
-	for _, forward := range forwards {
-		entries, err := forwardingEntry(forward, convert)
+		settled, ok := filterPayment(cfg.StartTime, cfg.EndTime, payment)
+		if !ok {
+			continue
+		}
+
+		if err := sanityCheckDuplicate(
+			uniqueHashes, settled.Hash,
+		); err != nil {
+			return nil, nil, err
+		}
+
+		paymentEntries, err := paymentEntry(*settled, toSelf, convert)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
-		reports = append(reports, entries...)
+		entries = append(entries, paymentEntries...)
 	}
 
-	return reports, nil
+	if err := <-errChan; err != nil {
+		return nil, nil, err
+	}
+
+	return circularPayments, entries, nil
 }
 
-// getCircularPayments returns a map of the payments that we made to our node.
-// Note that this function does only account for settled payments because it
-// is possible that we made a payment to ourselves, settled the invoice and
-// queried listPayments while the payment was still being settled back. We
-// rather examine their htlcs, since we will check whether they are settled in
-// our relevant period at a later stage.
-//
-// To allow for legacy nodes that have payments with duplicate payment hashes,
-// we allow for payments with duplicate payment hashes. We only fail if we
-// detect payments with the same payment hash where one is to our node and one
-// is not. This would make lookup in our circular payment map wrong for one of
-// the payments (resulting in bugs) and is not expected, because duplicate
-// payments are expected to reflect multiple attempts of the same payment.
-func getCircularPayments(ourPubkey string,
-	payments []lndclient.Payment) (map[string]bool, error) {
-
-	// Run through all payments and get those that were made to our own
-	// node. We identify these payments by payment hash so that we can
-	// identify associated invoices.
-	paymentsToSelf := make(map[string]bool)
-
-	for _, payment := range payments {
-		// If our payment has no htlc attempts, it has not yet been sent
-		// our by our node. This payment therefore cannot be a payment
-		// to ourselves within this accounting period; if we are paying
-		// a regular invoice, it will not be settled yet, and if we are
-		// making a keysend, the invoice will not exist in our node yet.
-		if len(payment.Htlcs) == 0 {
-			continue
-		}
+// streamInvoices consumes cfg.StreamInvoices to completion, producing report
+// entries for every invoice that settled within [cfg.StartTime, cfg.EndTime).
+// circularPayments is the completed map of payment hashes that our own node
+// paid, built by streamPayments, used to label invoices that we paid to
+// ourselves.
+func streamInvoices(ctx context.Context, cfg *OffChainConfig,
+	circularPayments map[string]bool, convert msatToFiat) (Report, error) {
 
-		// Since all htlcs go to the same node, we only need to get the
-		// destination of our first htlc to determine whether it's our
-		// own node. We expect the route this htlc took to have at least
-		// one hop, and fail if it does not.
-		hops := payment.Htlcs[0].Route.Hops
-		if len(hops) == 0 {
-			return nil, errNoHops
-		}
+	invoiceChan, errChan := cfg.StreamInvoices(ctx)
 
-		lastHop := hops[len(hops)-1]
-		toSelf := lastHop.PubKey == ourPubkey
+	var entries Report
 
-		// Before we add our entry to the map, we sanity check that if
-		// it has any duplicates, the value in the map is the same as
-		// the value we are about to add.
-		duplicateToSelf, ok := paymentsToSelf[payment.Hash.String()]
-		if ok && duplicateToSelf != toSelf {
-			return nil, errDifferentDuplicates
+	for invoice := range invoiceChan {
+		if !filterInvoice(cfg.StartTime, cfg.EndTime, invoice) {
+			continue
 		}
 
-		if toSelf {
-			paymentsToSelf[payment.Hash.String()] = toSelf
+		// If the invoice's payment hash is in our set of circular
+		// payments, we know that this payment was made to ourselves.
+		toSelf := circularPayments[invoice.Hash.String()]
+
+		invoiceEntries, err := invoiceEntry(
+			invoice, toSelf, cfg.GranularInvoiceHTLCs, convert,
+		)
+		if err != nil {
+			return nil, err
 		}
+
+		entries = append(entries, invoiceEntries...)
+	}
+
+	if err := <-errChan; err != nil {
+		return nil, err
 	}
 
-	return paymentsToSelf, nil
+	return entries, nil
 }
 
-// sanityCheckDuplicates checks that we have no payments with duplicate payment
-// hashes. We do not support accounting for duplicate payments.
-func sanityCheckDuplicates(payments []settledPayment) error {
-	uniqueHashes := make(map[lntypes.Hash]bool, len(payments))
+// getCircularPayment returns true if a single payment was made to our own
+// node. Note that this only considers payments with at least one htlc
+// attempt, because it is possible that we made a payment to ourselves,
+// settled the invoice and received this payment from lnd while the payment
+// itself was still being settled back. We examine htlcs rather than payment
+// status here, since settlement within our relevant period is checked
+// separately by filterPayment.
+func getCircularPayment(ourPubkey string,
+	payment lndclient.Payment) (bool, error) {
+
+	// If our payment has no htlc attempts, it has not yet been sent out
+	// by our node. This payment therefore cannot be a payment to
+	// ourselves within this accounting period; if we are paying a
+	// regular invoice, it will not be settled yet, and if we are making
+	// a keysend, the invoice will not exist in our node yet.
+	if len(payment.Htlcs) == 0 {
+		return false, nil
+	}
 
-	for _, payment := range payments {
-		_, ok := uniqueHashes[payment.Hash]
-		if ok {
-			return errDuplicatesNotSupported
-		}
+	// Since all htlcs go to the same node, we only need to get the
+	// destination of our first htlc to determine whether it's our own
+	// node. We expect the route this htlc took to have at least one hop,
+	// and fail if it does not.
+	hops := payment.Htlcs[0].Route.Hops
+	if len(hops) == 0 {
+		return false, errNoHops
+	}
+
+	lastHop := hops[len(hops)-1]
 
-		uniqueHashes[payment.Hash] = true
+	return lastHop.PubKey == ourPubkey, nil
+}
+
+// sanityCheckDuplicate records hash in uniqueHashes, returning
+// errDuplicatesNotSupported if it has already been seen. We do not support
+// accounting for duplicate payments.
+func sanityCheckDuplicate(uniqueHashes map[lntypes.Hash]bool,
+	hash lntypes.Hash) error {
+
+	if uniqueHashes[hash] {
+		return errDuplicatesNotSupported
 	}
 
+	uniqueHashes[hash] = true
+
 	return nil
 }
@@ -0,0 +1,172 @@
+package accounting
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/lntypes"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/shopspring/decimal"
+)
+
+// fakeStreamPayments returns a StreamPayments hook that delivers payments on
+// a channel, mimicking the shape of cfg.StreamPayments without actually
+// paginating anything.
+func fakeStreamPayments(payments []lndclient.Payment) func(ctx context.Context) (
+	<-chan lndclient.Payment, <-chan error) {
+
+	return func(_ context.Context) (<-chan lndclient.Payment, <-chan error) {
+		paymentChan := make(chan lndclient.Payment, len(payments))
+		errChan := make(chan error, 1)
+
+		for _, payment := range payments {
+			paymentChan <- payment
+		}
+		close(paymentChan)
+		errChan <- nil
+
+		return paymentChan, errChan
+	}
+}
+
+func noopConvert(amt lnwire.MilliSatoshi, _ time.Time) (decimal.Decimal, error) {
+	return decimal.NewFromInt(int64(amt)), nil
+}
+
+func settledPaymentWithHops(hash lntypes.Hash, settleTime time.Time,
+	hops []*lnrpc.Hop) lndclient.Payment {
+
+	return lndclient.Payment{
+		Hash:   hash,
+		Value:  lnwire.MilliSatoshi(100),
+		Status: lnrpc.Payment_SUCCEEDED,
+		Htlcs: []lndclient.PaymentHtlc{
+			{
+				Route:       &lnrpc.Route{Hops: hops},
+				ResolveTime: settleTime,
+			},
+		},
+	}
+}
+
+// TestStreamPayments tests the single-pass streaming logic that builds the
+// circular payment map and payment entries from cfg.StreamPayments.
+func TestStreamPayments(t *testing.T) {
+	var (
+		ourPubkey  = "us"
+		otherPeer  = "them"
+		settleTime = time.Unix(1000, 0)
+		startTime  = time.Unix(0, 0)
+		endTime    = time.Unix(2000, 0)
+	)
+
+	hash1 := lntypes.Hash{1}
+	hash2 := lntypes.Hash{2}
+
+	tests := []struct {
+		name        string
+		payments    []lndclient.Payment
+		expectErr   error
+		expectSelf  map[string]bool
+		expectCount int
+	}{
+		{
+			name: "payment to self is labeled circular",
+			payments: []lndclient.Payment{
+				settledPaymentWithHops(hash1, settleTime, []*lnrpc.Hop{
+					{PubKey: ourPubkey},
+				}),
+			},
+			expectSelf:  map[string]bool{hash1.String(): true},
+			expectCount: 1,
+		},
+		{
+			name: "payment to peer is not circular",
+			payments: []lndclient.Payment{
+				settledPaymentWithHops(hash1, settleTime, []*lnrpc.Hop{
+					{PubKey: otherPeer},
+				}),
+			},
+			expectSelf:  map[string]bool{},
+			expectCount: 1,
+		},
+		{
+			name: "duplicate hash resolving to different destinations errors",
+			payments: []lndclient.Payment{
+				settledPaymentWithHops(hash1, settleTime, []*lnrpc.Hop{
+					{PubKey: ourPubkey},
+				}),
+				settledPaymentWithHops(hash1, settleTime, []*lnrpc.Hop{
+					{PubKey: otherPeer},
+				}),
+			},
+			expectErr: errDifferentDuplicates,
+		},
+		{
+			name: "duplicate settled payment hash errors",
+			payments: []lndclient.Payment{
+				settledPaymentWithHops(hash2, settleTime, []*lnrpc.Hop{
+					{PubKey: otherPeer},
+				}),
+				settledPaymentWithHops(hash2, settleTime, []*lnrpc.Hop{
+					{PubKey: otherPeer},
+				}),
+			},
+			expectErr: errDuplicatesNotSupported,
+		},
+		{
+			name: "payment with no hops errors",
+			payments: []lndclient.Payment{
+				settledPaymentWithHops(hash1, settleTime, nil),
+			},
+			expectErr: errNoHops,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := &OffChainConfig{
+				OwnPubKey:      ourPubkey,
+				StartTime:      startTime,
+				EndTime:        endTime,
+				StreamPayments: fakeStreamPayments(test.payments),
+			}
+
+			circular, entries, err := streamPayments(
+				context.Background(), cfg, noopConvert,
+			)
+
+			if test.expectErr != nil {
+				if err != test.expectErr {
+					t.Fatalf("expected error %v, got %v",
+						test.expectErr, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(entries) != test.expectCount {
+				t.Fatalf("expected %v entries, got %v",
+					test.expectCount, len(entries))
+			}
+
+			for hash, self := range test.expectSelf {
+				if circular[hash] != self {
+					t.Errorf("expected circular[%v] = %v, "+
+						"got %v", hash, self, circular[hash])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,462 @@
+package accounting
+
+import (
+	"context"
+	"time"
+
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/shopspring/decimal"
+)
+
+// OnChainCategory identifies why faraday believes a given on-chain
+// transaction occurred. Prior to this, every on-chain transaction that did
+// not move funds to/from an exchange-style external address simply showed
+// up as an opaque send or receive; OnChainCategory lets us attribute a
+// transaction to the channel lifecycle event that produced it instead.
+type OnChainCategory int
+
+const (
+	// OnChainCategoryRegular is a transaction that we could not attribute
+	// to any channel lifecycle event, and is reported as a plain send or
+	// receive.
+	OnChainCategoryRegular OnChainCategory = iota
+
+	// OnChainCategoryChannelOpen is a transaction that funded one of our
+	// channels.
+	OnChainCategoryChannelOpen
+
+	// OnChainCategoryCoopClose is a transaction that cooperatively closed
+	// one of our channels.
+	OnChainCategoryCoopClose
+
+	// OnChainCategoryForceClose is a commitment transaction broadcast to
+	// force close one of our channels.
+	OnChainCategoryForceClose
+
+	// OnChainCategorySweep is a transaction that swept our balance out
+	// of a force closed channel's commitment transaction once its
+	// timelock expired.
+	OnChainCategorySweep
+
+	// OnChainCategoryHTLCTimeout is a transaction that recovered our
+	// funds from a timed out htlc output on a force closed channel.
+	OnChainCategoryHTLCTimeout
+
+	// OnChainCategoryAnchorSweep is a transaction that swept an anchor
+	// output from a force closed channel's commitment transaction.
+	OnChainCategoryAnchorSweep
+)
+
+// String returns the human readable name of an OnChainCategory.
+func (c OnChainCategory) String() string {
+	switch c {
+	case OnChainCategoryChannelOpen:
+		return "channel open"
+
+	case OnChainCategoryCoopClose:
+		return "cooperative close"
+
+	case OnChainCategoryForceClose:
+		return "force close"
+
+	case OnChainCategorySweep:
+		return "sweep"
+
+	case OnChainCategoryHTLCTimeout:
+		return "htlc timeout"
+
+	case OnChainCategoryAnchorSweep:
+		return "anchor sweep"
+
+	default:
+		return "regular"
+	}
+}
+
+// OnChainConfig contains the functionality required to produce an on chain
+// report that attributes wallet transactions to channel opens, closes,
+// sweeps and timeouts.
+type OnChainConfig struct {
+	// ListTransactions lists all of our wallet's on chain transactions.
+	ListTransactions func() ([]lndclient.Transaction, error)
+
+	// ClosedChannels lists the channels that our node has closed, used
+	// to attribute closing transactions to the channel they closed and
+	// split out the components of their settled balance.
+	ClosedChannels func() ([]lndclient.ClosedChannel, error)
+
+	// PendingChannels returns our node's pending channels, used to
+	// attribute sweep, htlc timeout and anchor sweep transactions back
+	// to the force closed channel that produced them.
+	PendingChannels func() (*lndclient.PendingChannels, error)
+
+	// StartTime is the time from which the report should be created,
+	// inclusive.
+	StartTime time.Time
+
+	// EndTime is the time until which the report should be created,
+	// exclusive.
+	EndTime time.Time
+}
+
+// OnChainReport produces a report of our on chain activity over
+// [cfg.StartTime, cfg.EndTime), classifying each transaction by the channel
+// lifecycle event that produced it rather than reporting it as an opaque
+// send or receive.
+func OnChainReport(ctx context.Context, cfg *OnChainConfig) (Report, error) {
+	getPrice, err := getConversion(ctx, cfg.StartTime, cfg.EndTime)
+	if err != nil {
+		return nil, err
+	}
+
+	txns, err := cfg.ListTransactions()
+	if err != nil {
+		return nil, err
+	}
+
+	closed, err := cfg.ClosedChannels()
+	if err != nil {
+		return nil, err
+	}
+
+	pending, err := cfg.PendingChannels()
+	if err != nil {
+		return nil, err
+	}
+
+	classifier := newTxClassifier(closed, pending)
+
+	var report Report
+	for _, tx := range txns {
+		blockTime := time.Unix(tx.Timestamp, 0)
+		if blockTime.Before(cfg.StartTime) || !blockTime.Before(cfg.EndTime) {
+			continue
+		}
+
+		entries, err := onChainEntry(classifier, tx, blockTime, getPrice)
+		if err != nil {
+			return nil, err
+		}
+
+		report = append(report, entries...)
+	}
+
+	return report, nil
+}
+
+// txClassifier looks up the OnChainCategory and originating channel (where
+// relevant) for a transaction, based on the set of closed and pending
+// channels current at the time the report is run.
+type txClassifier struct {
+	// channelOpens maps a channel funding txid to the channel point it
+	// funded.
+	channelOpens map[string]string
+
+	// channelCloses maps a closing txid to the closed channel that
+	// produced it.
+	channelCloses map[string]lndclient.ClosedChannel
+
+	// sweeps maps a sweep/htlc-timeout/anchor-sweep outpoint to the
+	// category it belongs to, sourced from our currently force closed
+	// channels' pending htlcs and anchor outputs.
+	sweeps map[string]OnChainCategory
+}
+
+// newTxClassifier builds a txClassifier from the current set of closed and
+// pending channels.
+func newTxClassifier(closed []lndclient.ClosedChannel,
+	pending *lndclient.PendingChannels) *txClassifier {
+
+	c := &txClassifier{
+		channelOpens:  make(map[string]string),
+		channelCloses: make(map[string]lndclient.ClosedChannel),
+		sweeps:        make(map[string]OnChainCategory),
+	}
+
+	for _, channel := range closed {
+		c.channelCloses[channel.ClosingTxid] = channel
+
+		if fundingTxid := channelPointTxid(channel.ChannelPoint); fundingTxid != "" {
+			c.channelOpens[fundingTxid] = channel.ChannelPoint
+		}
+	}
+
+	if pending == nil {
+		return c
+	}
+
+	for _, force := range pending.ForceClosingChannels {
+		if fundingTxid := channelPointTxid(force.ChannelPoint); fundingTxid != "" {
+			c.channelOpens[fundingTxid] = force.ChannelPoint
+		}
+
+		c.sweeps[force.ClosingTxid] = OnChainCategoryForceClose
+
+		if force.AnchorOutpoint != "" {
+			c.sweeps[force.AnchorOutpoint] = OnChainCategoryAnchorSweep
+		}
+
+		for _, htlc := range force.PendingHtlcs {
+			category := OnChainCategorySweep
+			if htlc.IsIncoming {
+				category = OnChainCategoryHTLCTimeout
+			}
+
+			c.sweeps[htlc.Outpoint] = category
+		}
+	}
+
+	return c
+}
+
+// categorize returns the category that a transaction belongs to, along with
+// the closed channel it is associated with, if any.
+func (c *txClassifier) categorize(tx lndclient.Transaction) (OnChainCategory,
+	*lndclient.ClosedChannel) {
+
+	if channel, ok := c.channelCloses[tx.TxHash]; ok {
+		if channel.CloseType == lndclient.CloseTypeCooperative {
+			return OnChainCategoryCoopClose, &channel
+		}
+
+		return OnChainCategoryForceClose, &channel
+	}
+
+	if _, ok := c.channelOpens[tx.TxHash]; ok {
+		return OnChainCategoryChannelOpen, nil
+	}
+
+	if category, ok := c.sweeps[tx.TxHash]; ok {
+		return category, nil
+	}
+
+	return OnChainCategoryRegular, nil
+}
+
+// channelPointTxid extracts the funding txid from a channel point formatted
+// as "txid:outputindex".
+func channelPointTxid(chanPoint string) string {
+	for i := 0; i < len(chanPoint); i++ {
+		if chanPoint[i] == ':' {
+			return chanPoint[:i]
+		}
+	}
+
+	return ""
+}
+
+// onChainEntry produces the harmony entries for a single on chain
+// transaction, each priced via convert at the transaction's block time. A
+// channel close is split into the "channel principal returned", "commit fee
+// paid" and "counterparty balance" components that a plain send/receive
+// entry would otherwise hide; any other transaction gets an amount entry and,
+// if it paid a fee, a fee entry noted as "sweep fee paid" for sweep
+// categories and "network fee paid" otherwise.
+func onChainEntry(classifier *txClassifier, tx lndclient.Transaction,
+	blockTime time.Time, convert msatToFiat) ([]*HarmonyEntry, error) {
+
+	category, closedChannel := classifier.categorize(tx)
+
+	note := category.String()
+
+	base := &HarmonyEntry{
+		Timestamp: blockTime,
+		TxID:      tx.TxHash,
+		Reference: tx.TxHash,
+		Note:      note,
+		OnChain:   true,
+		Credit:    tx.Amount > 0,
+	}
+
+	if category != OnChainCategoryCoopClose &&
+		category != OnChainCategoryForceClose {
+
+		amtFiat, err := convertSats(convert, absSats(tx.Amount), blockTime)
+		if err != nil {
+			return nil, err
+		}
+
+		entry := *base
+		entry.Amount = satsToMsat(absSats(tx.Amount))
+		entry.FiatValue = amtFiat
+		entry.Type = onChainEntryType(category)
+
+		feeEntry, err := onChainFeeEntry(tx, category, blockTime, convert)
+		if err != nil {
+			return nil, err
+		}
+
+		entries := []*HarmonyEntry{&entry}
+		if feeEntry != nil {
+			entries = append(entries, feeEntry)
+		}
+
+		return entries, nil
+	}
+
+	return closeEntries(*base, tx, closedChannel, blockTime, convert)
+}
+
+// closeEntries splits a channel close transaction into its component parts:
+// the principal we get back, the commit fee we paid and the counterparty's
+// balance, recorded for completeness. No sweep fee entry is produced here;
+// sweep fees are recorded by onChainEntry on the sweep transactions
+// themselves.
+// The closed channel itself is only known once it has been fully resolved;
+// for a pending force close we have nothing but the commitment transaction
+// to go on, so we fall back to reporting its on-chain amount unsplit.
+func closeEntries(base HarmonyEntry, tx lndclient.Transaction,
+	channel *lndclient.ClosedChannel, blockTime time.Time,
+	convert msatToFiat) ([]*HarmonyEntry, error) {
+
+	if channel == nil {
+		amtFiat, err := convertSats(convert, absSats(tx.Amount), blockTime)
+		if err != nil {
+			return nil, err
+		}
+
+		base.Amount = satsToMsat(absSats(tx.Amount))
+		base.FiatValue = amtFiat
+		base.Type = EntryTypeChannelClose
+
+		return []*HarmonyEntry{&base}, nil
+	}
+
+	principalFiat, err := convertSats(
+		convert, channel.SettledBalance, blockTime,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	principal := base
+	principal.Amount = satsToMsat(channel.SettledBalance)
+	principal.FiatValue = principalFiat
+	principal.Note = "channel principal returned"
+	principal.Type = EntryTypeChannelClose
+
+	entries := []*HarmonyEntry{&principal}
+
+	if channel.CommitFee != 0 {
+		feeFiat, err := convertSats(convert, channel.CommitFee, blockTime)
+		if err != nil {
+			return nil, err
+		}
+
+		fee := base
+		fee.Amount = satsToMsat(channel.CommitFee)
+		fee.FiatValue = feeFiat
+		fee.Note = "commit fee paid"
+		fee.Type = EntryTypeFee
+		fee.Credit = false
+
+		entries = append(entries, &fee)
+	}
+
+	counterpartyBalance := channel.Capacity - channel.SettledBalance -
+		channel.CommitFee
+	if counterpartyBalance > 0 {
+		counterpartyFiat, err := convertSats(
+			convert, counterpartyBalance, blockTime,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		counterparty := base
+		counterparty.Amount = satsToMsat(counterpartyBalance)
+		counterparty.FiatValue = counterpartyFiat
+		counterparty.Note = "counterparty balance"
+		counterparty.Type = EntryTypeChannelClose
+		counterparty.Credit = false
+
+		entries = append(entries, &counterparty)
+	}
+
+	return entries, nil
+}
+
+// onChainFeeEntry produces an entry for the on-chain fee paid to broadcast
+// tx, if any.
+func onChainFeeEntry(tx lndclient.Transaction, category OnChainCategory,
+	blockTime time.Time, convert msatToFiat) (*HarmonyEntry, error) {
+
+	if tx.Fee == 0 {
+		return nil, nil
+	}
+
+	feeFiat, err := convertSats(convert, tx.Fee, blockTime)
+	if err != nil {
+		return nil, err
+	}
+
+	return &HarmonyEntry{
+		Timestamp: blockTime,
+		Amount:    satsToMsat(tx.Fee),
+		FiatValue: feeFiat,
+		TxID:      tx.TxHash,
+		Reference: tx.TxHash,
+		Note:      onChainFeeNote(category),
+		Type:      EntryTypeFee,
+		OnChain:   true,
+		Credit:    false,
+	}, nil
+}
+
+// onChainFeeNote returns the note a transaction's mining fee should be
+// recorded under, based on the category of transaction that paid it.
+func onChainFeeNote(category OnChainCategory) string {
+	switch category {
+	case OnChainCategorySweep, OnChainCategoryHTLCTimeout,
+		OnChainCategoryAnchorSweep:
+
+		return "sweep fee paid"
+
+	default:
+		return "network fee paid"
+	}
+}
+
+// onChainEntryType maps an OnChainCategory to the EntryType we record it
+// under in our report.
+func onChainEntryType(category OnChainCategory) EntryType {
+	switch category {
+	case OnChainCategoryChannelOpen:
+		return EntryTypeChannelOpen
+
+	case OnChainCategorySweep, OnChainCategoryHTLCTimeout,
+		OnChainCategoryAnchorSweep:
+
+		return EntryTypeSweep
+
+	default:
+		return EntryTypeOnChain
+	}
+}
+
+// convertSats is a small helper that converts a sat amount to msat before
+// calling convert, since msatToFiat prices millisatoshi amounts.
+func convertSats(convert msatToFiat, sats int64,
+	ts time.Time) (decimal.Decimal, error) {
+
+	return convert(satsToMsat(sats), ts)
+}
+
+// absSats returns the absolute value of a signed satoshi amount. Wallet
+// transaction amounts are signed to indicate direction (negative for an
+// outgoing transaction), but satsToMsat converts into the unsigned
+// lnwire.MilliSatoshi that HarmonyEntry.Amount expects, relying on
+// HarmonyEntry.Credit to carry the sign instead.
+func absSats(sats int64) int64 {
+	if sats < 0 {
+		return -sats
+	}
+
+	return sats
+}
+
+// satsToMsat converts a satoshi amount to millisatoshis.
+func satsToMsat(sats int64) lnwire.MilliSatoshi {
+	return lnwire.MilliSatoshi(sats * 1000)
+}
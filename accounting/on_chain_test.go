@@ -0,0 +1,307 @@
+package accounting
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/shopspring/decimal"
+)
+
+// TestTxClassifierCategorize tests that a txClassifier correctly attributes
+// transactions to channel opens, closes, sweeps and htlc timeouts.
+func TestTxClassifierCategorize(t *testing.T) {
+	closed := []lndclient.ClosedChannel{
+		{
+			ChannelPoint: "open1:0",
+			ClosingTxid:  "coopclose",
+			CloseType:    lndclient.CloseTypeCooperative,
+		},
+		{
+			ChannelPoint: "open2:0",
+			ClosingTxid:  "forceclose",
+			CloseType:    lndclient.CloseTypeLocalForce,
+		},
+	}
+
+	pending := &lndclient.PendingChannels{
+		ForceClosingChannels: []lndclient.ForceCloseChannel{
+			{
+				ChannelPoint:   "open3:0",
+				ClosingTxid:    "pendingforce",
+				AnchorOutpoint: "anchorsweep:0",
+				PendingHtlcs: []lndclient.PendingHtlc{
+					{Outpoint: "sweep:0", IsIncoming: false},
+					{Outpoint: "timeout:0", IsIncoming: true},
+				},
+			},
+		},
+	}
+
+	classifier := newTxClassifier(closed, pending)
+
+	tests := []struct {
+		name         string
+		txHash       string
+		wantCategory OnChainCategory
+		wantChannel  bool
+	}{
+		{
+			name:         "cooperative close",
+			txHash:       "coopclose",
+			wantCategory: OnChainCategoryCoopClose,
+			wantChannel:  true,
+		},
+		{
+			name:         "force close",
+			txHash:       "forceclose",
+			wantCategory: OnChainCategoryForceClose,
+			wantChannel:  true,
+		},
+		{
+			name:         "channel open",
+			txHash:       "open1",
+			wantCategory: OnChainCategoryChannelOpen,
+		},
+		{
+			name:         "pending force close",
+			txHash:       "pendingforce",
+			wantCategory: OnChainCategoryForceClose,
+		},
+		{
+			name:         "sweep",
+			txHash:       "sweep:0",
+			wantCategory: OnChainCategorySweep,
+		},
+		{
+			name:         "htlc timeout",
+			txHash:       "timeout:0",
+			wantCategory: OnChainCategoryHTLCTimeout,
+		},
+		{
+			name:         "anchor sweep",
+			txHash:       "anchorsweep:0",
+			wantCategory: OnChainCategoryAnchorSweep,
+		},
+		{
+			name:         "regular",
+			txHash:       "unknown",
+			wantCategory: OnChainCategoryRegular,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			category, channel := classifier.categorize(
+				lndclient.Transaction{TxHash: test.txHash},
+			)
+
+			if category != test.wantCategory {
+				t.Errorf("expected category %v, got %v",
+					test.wantCategory, category)
+			}
+
+			if (channel != nil) != test.wantChannel {
+				t.Errorf("expected channel present: %v, got: %v",
+					test.wantChannel, channel != nil)
+			}
+		})
+	}
+}
+
+// TestCloseEntries tests that closeEntries splits a channel close's settled
+// balance into its component parts, and falls back to the commitment
+// transaction's own amount when the closed channel is not yet known.
+func TestCloseEntries(t *testing.T) {
+	blockTime := time.Unix(1000, 0)
+
+	convert := func(amt lnwire.MilliSatoshi,
+		_ time.Time) (decimal.Decimal, error) {
+
+		return decimal.NewFromInt(int64(amt)), nil
+	}
+
+	base := HarmonyEntry{
+		Timestamp: blockTime,
+		TxID:      "tx",
+		Reference: "tx",
+	}
+
+	t.Run("pending force close uses tx amount", func(t *testing.T) {
+		tx := lndclient.Transaction{TxHash: "tx", Amount: 100}
+
+		entries, err := closeEntries(base, tx, nil, blockTime, convert)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(entries) != 1 {
+			t.Fatalf("expected 1 entry, got %v", len(entries))
+		}
+
+		if entries[0].Amount != satsToMsat(100) {
+			t.Errorf("expected amount %v, got %v",
+				satsToMsat(100), entries[0].Amount)
+		}
+	})
+
+	t.Run("resolved close splits principal, fee and counterparty",
+		func(t *testing.T) {
+
+			tx := lndclient.Transaction{TxHash: "tx", Amount: 80}
+			channel := &lndclient.ClosedChannel{
+				Capacity:       100,
+				SettledBalance: 80,
+				CommitFee:      5,
+			}
+
+			entries, err := closeEntries(
+				base, tx, channel, blockTime, convert,
+			)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(entries) != 3 {
+				t.Fatalf("expected 3 entries, got %v",
+					len(entries))
+			}
+
+			principal, fee, counterparty :=
+				entries[0], entries[1], entries[2]
+
+			if principal.Amount != satsToMsat(80) {
+				t.Errorf("expected principal %v, got %v",
+					satsToMsat(80), principal.Amount)
+			}
+
+			if fee.Amount != satsToMsat(5) {
+				t.Errorf("expected fee %v, got %v",
+					satsToMsat(5), fee.Amount)
+			}
+
+			wantCounterparty := satsToMsat(100 - 80 - 5)
+			if counterparty.Amount != wantCounterparty {
+				t.Errorf("expected counterparty balance %v, "+
+					"got %v", wantCounterparty,
+					counterparty.Amount)
+			}
+
+			if counterparty.Note != "counterparty balance" {
+				t.Errorf("expected counterparty balance note, "+
+					"got %v", counterparty.Note)
+			}
+		})
+
+	t.Run("no counterparty balance when capacity unknown",
+		func(t *testing.T) {
+
+			tx := lndclient.Transaction{TxHash: "tx", Amount: 80}
+			channel := &lndclient.ClosedChannel{
+				SettledBalance: 80,
+			}
+
+			entries, err := closeEntries(
+				base, tx, channel, blockTime, convert,
+			)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(entries) != 1 {
+				t.Fatalf("expected 1 entry, got %v",
+					len(entries))
+			}
+		})
+}
+
+// TestOnChainEntry tests that onChainEntry prices a regular send or
+// channel-open transaction by its absolute amount regardless of the sign of
+// tx.Amount, and labels its fee entry appropriately for its category.
+func TestOnChainEntry(t *testing.T) {
+	blockTime := time.Unix(1000, 0)
+
+	convert := func(amt lnwire.MilliSatoshi,
+		_ time.Time) (decimal.Decimal, error) {
+
+		return decimal.NewFromInt(int64(amt)), nil
+	}
+
+	tests := []struct {
+		name       string
+		tx         lndclient.Transaction
+		classifier *txClassifier
+		wantAmount lnwire.MilliSatoshi
+		wantCredit bool
+		wantNote   string
+	}{
+		{
+			name: "outgoing regular send",
+			tx: lndclient.Transaction{
+				TxHash: "regular",
+				Amount: -100,
+				Fee:    5,
+			},
+			classifier: newTxClassifier(nil, nil),
+			wantAmount: satsToMsat(100),
+			wantCredit: false,
+			wantNote:   "network fee paid",
+		},
+		{
+			name: "outgoing channel open",
+			tx: lndclient.Transaction{
+				TxHash: "opentx",
+				Amount: -200,
+				Fee:    7,
+			},
+			classifier: newTxClassifier([]lndclient.ClosedChannel{
+				{ChannelPoint: "opentx:0"},
+			}, nil),
+			wantAmount: satsToMsat(200),
+			wantCredit: false,
+			wantNote:   "network fee paid",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			entries, err := onChainEntry(
+				test.classifier, test.tx, blockTime, convert,
+			)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(entries) != 2 {
+				t.Fatalf("expected 2 entries, got %v",
+					len(entries))
+			}
+
+			amount, fee := entries[0], entries[1]
+
+			if amount.Amount != test.wantAmount {
+				t.Errorf("expected amount %v, got %v",
+					test.wantAmount, amount.Amount)
+			}
+
+			if amount.Credit != test.wantCredit {
+				t.Errorf("expected credit %v, got %v",
+					test.wantCredit, amount.Credit)
+			}
+
+			if fee.Note != test.wantNote {
+				t.Errorf("expected fee note %q, got %q",
+					test.wantNote, fee.Note)
+			}
+		})
+	}
+}
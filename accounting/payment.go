@@ -0,0 +1,98 @@
+package accounting
+
+import (
+	"time"
+
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/lnrpc"
+)
+
+// settledPayment wraps an lndclient.Payment that we have confirmed settled,
+// together with the time that its value should be priced at. A payment may
+// make several htlc attempts before one of them succeeds; we always price
+// the payment using the resolve time of the htlc that actually settled it.
+type settledPayment struct {
+	lndclient.Payment
+
+	// SettleTime is the time that this payment's successful htlc
+	// resolved.
+	SettleTime time.Time
+}
+
+// filterPayment checks a single payment for settlement and for falling
+// within [start, end), returning its settledPayment representation if both
+// hold.
+func filterPayment(start, end time.Time,
+	payment lndclient.Payment) (*settledPayment, bool) {
+
+	if payment.Status != lnrpc.Payment_SUCCEEDED || len(payment.Htlcs) == 0 {
+		return nil, false
+	}
+
+	resolveTime := payment.Htlcs[len(payment.Htlcs)-1].ResolveTime
+	if resolveTime.Before(start) || !resolveTime.Before(end) {
+		return nil, false
+	}
+
+	return &settledPayment{
+		Payment:    payment,
+		SettleTime: resolveTime,
+	}, true
+}
+
+// filterInvoice reports whether a single invoice settled within
+// [start, end).
+func filterInvoice(start, end time.Time, invoice lndclient.Invoice) bool {
+	settleTime := invoice.SettleDate
+
+	return !settleTime.Before(start) && settleTime.Before(end)
+}
+
+// paymentEntry creates the harmony entries for a settled payment: one entry
+// for the value sent, and (if a routing fee was paid) one for the fee.
+func paymentEntry(payment settledPayment, toSelf bool,
+	convert msatToFiat) ([]*HarmonyEntry, error) {
+
+	amtFiat, err := convert(payment.Value, payment.SettleTime)
+	if err != nil {
+		return nil, err
+	}
+
+	entryType := EntryTypePayment
+	if toSelf {
+		entryType = EntryTypePaymentToSelf
+	}
+
+	entries := []*HarmonyEntry{{
+		Timestamp: payment.SettleTime,
+		Amount:    payment.Value,
+		FiatValue: amtFiat,
+		TxID:      payment.Hash.String(),
+		Reference: payment.Hash.String(),
+		Type:      entryType,
+		OnChain:   false,
+		Credit:    false,
+	}}
+
+	if payment.Fee == 0 {
+		return entries, nil
+	}
+
+	feeFiat, err := convert(payment.Fee, payment.SettleTime)
+	if err != nil {
+		return nil, err
+	}
+
+	entries = append(entries, &HarmonyEntry{
+		Timestamp: payment.SettleTime,
+		Amount:    payment.Fee,
+		FiatValue: feeFiat,
+		TxID:      payment.Hash.String(),
+		Reference: payment.Hash.String(),
+		Type:      EntryTypeFee,
+		OnChain:   false,
+		Credit:    false,
+	})
+
+	return entries, nil
+}
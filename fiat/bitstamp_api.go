@@ -0,0 +1,168 @@
+package fiat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// bitstampAPIHost is the host of bitstamp's api.
+	bitstampAPIHost = "https://www.bitstamp.net/api/v2"
+
+	// bitstampOHLCEndpoint is the endpoint we hit to get open/high/low/
+	// close data for the BTC/USD pair.
+	bitstampOHLCEndpoint = bitstampAPIHost + "/ohlc/btcusd"
+)
+
+// bitstampStep maps our granularity levels to the step (in seconds) that
+// bitstamp's OHLC endpoint expects. Bitstamp only supports a fixed set of
+// step values; each of our granularities maps to the step of exactly the
+// same width.
+var bitstampStep = map[Granularity]int{
+	GranularityMinute:   60,
+	Granularity5Minute:  300,
+	Granularity15Minute: 900,
+	Granularity30Minute: 1800,
+	GranularityHour:     3600,
+	Granularity6Hour:    21600,
+	Granularity12Hour:   43200,
+	GranularityDay:      86400,
+}
+
+var (
+	// bitstampMaxGranularityPeriod holds the maximum period we may query
+	// for a given granularity from bitstamp's api. Bitstamp caps a single
+	// request at 1000 candles, so the maximum period is 1000*step.
+	bitstampMaxGranularityPeriod = map[Granularity]time.Duration{
+		GranularityMinute:   time.Minute * 1000,
+		Granularity5Minute:  time.Minute * 5 * 1000,
+		Granularity15Minute: time.Minute * 15 * 1000,
+		Granularity30Minute: time.Minute * 30 * 1000,
+		GranularityHour:     time.Hour * 1000,
+		Granularity6Hour:    time.Hour * 6 * 1000,
+		Granularity12Hour:   time.Hour * 12 * 1000,
+		GranularityDay:      time.Hour * 24 * 1000,
+	}
+
+	// bitstampMinGranularityPeriod holds the minimum period we may query
+	// for a given granularity from bitstamp's api.
+	bitstampMinGranularityPeriod = map[Granularity]time.Duration{
+		GranularityMinute:   time.Minute,
+		Granularity5Minute:  time.Minute * 5,
+		Granularity15Minute: time.Minute * 15,
+		Granularity30Minute: time.Minute * 30,
+		GranularityHour:     time.Hour,
+		Granularity6Hour:    time.Hour * 6,
+		Granularity12Hour:   time.Hour * 12,
+		GranularityDay:      time.Hour * 24,
+	}
+)
+
+// errUnsupportedBitstampGranularity is returned when we are asked for a
+// granularity that bitstamp's OHLC endpoint has no equivalent step for.
+var errUnsupportedBitstampGranularity = fmt.Errorf("unsupported bitstamp " +
+	"granularity")
+
+// bitstampAPI implements the PriceBackend interface, getting historical
+// Bitcoin prices from bitstamp's OHLC endpoint.
+type bitstampAPI struct {
+	// granularity represents the granularity requested, expressed using
+	// our own Granularity type and mapped to bitstamp's step parameter
+	// via bitstampStep.
+	granularity Granularity
+
+	// query is the function that makes the http call out to bitstamp's
+	// api. It is set within the struct so that it can be mocked for
+	// testing.
+	query func(start, end time.Time, g Granularity) ([]byte, error)
+
+	// convert produces usd prices from the output of the query function.
+	// It is set within the struct so that it can be mocked for testing.
+	convert func([]byte) ([]*usdPrice, error)
+}
+
+// GetPrices retrieves price information from bitstamp's api, splitting the
+// request into multiple calls if required by bitstamp's granularity/period
+// limits.
+func (b *bitstampAPI) GetPrices(ctx context.Context, startTime,
+	endTime time.Time) ([]*usdPrice, error) {
+
+	return splitRequest(ctx, startTime, endTime, granularityLimits{
+		max: bitstampMaxGranularityPeriod,
+		min: bitstampMinGranularityPeriod,
+	}, b.granularity, b.query, b.convert)
+}
+
+// queryBitstamp calls bitstamp's OHLC endpoint for the period [start, end] at
+// the step associated with granularity g.
+func queryBitstamp(start, end time.Time, g Granularity) ([]byte, error) {
+	step, ok := bitstampStep[g]
+	if !ok {
+		return nil, errUnsupportedBitstampGranularity
+	}
+
+	limit := int(end.Sub(start).Seconds()) / step
+	if limit < 1 {
+		limit = 1
+	}
+
+	url := fmt.Sprintf("%v?step=%v&limit=%v&start=%v&end=%v",
+		bitstampOHLCEndpoint, step, limit, start.Unix(), end.Unix())
+
+	// nolint: gosec
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return ioutil.ReadAll(resp.Body)
+}
+
+// bitstampOHLCResponse mirrors the response body returned by bitstamp's OHLC
+// endpoint.
+type bitstampOHLCResponse struct {
+	Data struct {
+		OHLC []struct {
+			Timestamp string `json:"timestamp"`
+			Close     string `json:"close"`
+		} `json:"ohlc"`
+	} `json:"data"`
+}
+
+// parseBitstampData parses the bytes returned by bitstamp's api into a set of
+// usd prices, using the closing price of each candle.
+func parseBitstampData(data []byte) ([]*usdPrice, error) {
+	var resp bitstampOHLCResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, err
+	}
+
+	prices := make([]*usdPrice, len(resp.Data.OHLC))
+	for i, candle := range resp.Data.OHLC {
+		var unixSeconds int64
+		if _, err := fmt.Sscanf(
+			candle.Timestamp, "%d", &unixSeconds,
+		); err != nil {
+			return nil, err
+		}
+
+		price, err := decimal.NewFromString(candle.Close)
+		if err != nil {
+			return nil, err
+		}
+
+		prices[i] = &usdPrice{
+			timestamp: time.Unix(unixSeconds, 0),
+			price:     price,
+		}
+	}
+
+	return prices, nil
+}
@@ -0,0 +1,215 @@
+package fiat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// bucketSize is the width of the time bucket that a single cache file on
+// disk covers. Bucketing by day (rather than caching individual price
+// points) keeps the number of files on disk manageable while still letting
+// overlapping accounting runs reuse almost all of a previous run's queries.
+const bucketSize = time.Hour * 24
+
+// cachedPriceBackend wraps a PriceBackend with a filesystem-backed cache,
+// keyed by provider, granularity and timestamp bucket. Accounting reports
+// (particularly tax reports) are frequently regenerated over overlapping or
+// identical date ranges, so caching historical prices on disk avoids
+// repeatedly hitting rate limited third party apis for data that cannot
+// change once it has settled.
+type cachedPriceBackend struct {
+	// backend is the underlying PriceBackend that we query on a cache
+	// miss.
+	backend PriceBackend
+
+	// source identifies the provider being wrapped, used to namespace
+	// cache files on disk so that different providers never collide.
+	source PriceSource
+
+	// granularity is the granularity that prices are being cached at.
+	granularity Granularity
+
+	// cacheDir is the directory that cache files are read from and
+	// written to.
+	cacheDir string
+}
+
+// NewCachedPriceBackend wraps backend with a disk cache rooted at cacheDir.
+func NewCachedPriceBackend(backend PriceBackend, source PriceSource,
+	granularity Granularity, cacheDir string) PriceBackend {
+
+	return &cachedPriceBackend{
+		backend:     backend,
+		source:      source,
+		granularity: granularity,
+		cacheDir:    cacheDir,
+	}
+}
+
+// GetPrices returns usd prices for [startTime, endTime], serving whole
+// buckets from disk where possible and only querying the wrapped backend for
+// buckets we have not yet cached.
+func (c *cachedPriceBackend) GetPrices(ctx context.Context, startTime,
+	endTime time.Time) ([]*usdPrice, error) {
+
+	var (
+		prices      []*usdPrice
+		bucketStart = startTime.Truncate(bucketSize)
+	)
+
+	for bucketStart.Before(endTime) {
+		bucketEnd := bucketStart.Add(bucketSize)
+
+		bucketPrices, err := c.getBucket(ctx, bucketStart, bucketEnd, endTime)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, price := range bucketPrices {
+			if price.timestamp.Before(startTime) ||
+				price.timestamp.After(endTime) {
+
+				continue
+			}
+
+			prices = append(prices, price)
+		}
+
+		bucketStart = bucketEnd
+	}
+
+	return prices, nil
+}
+
+// getBucket returns the prices for a single cache bucket, reading them from
+// disk if present and otherwise querying the wrapped backend and persisting
+// the result for next time.
+//
+// The bucket is day-aligned, so bucketEnd can be in the future relative to
+// both the caller's requested endTime and wall clock time (most obviously
+// for the bucket covering "today"). Querying the backend for a range that
+// extends into the future would be rejected by splitRequest's future-range
+// check, and caching a bucket before it has fully elapsed would permanently
+// freeze a partial result for it. To avoid both, we clip the backend query
+// to whichever of bucketEnd, endTime or now is earliest, and only persist a
+// cache file once the bucket's full width has actually elapsed.
+func (c *cachedPriceBackend) getBucket(ctx context.Context, bucketStart,
+	bucketEnd, endTime time.Time) ([]*usdPrice, error) {
+
+	path := c.cachePath(bucketStart)
+
+	cached, err := readCacheFile(path)
+	switch {
+	case err == nil:
+		return cached, nil
+
+	case os.IsNotExist(err):
+		// Fall through to query the backend below.
+
+	default:
+		return nil, err
+	}
+
+	queryEnd := bucketEnd
+	if endTime.Before(queryEnd) {
+		queryEnd = endTime
+	}
+
+	now := time.Now()
+	if now.Before(queryEnd) {
+		queryEnd = now
+	}
+
+	prices, err := c.backend.GetPrices(ctx, bucketStart, queryEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only cache this bucket if we queried its full width - otherwise we
+	// would be freezing a partial (typically "today so far") result that
+	// a later run, with a later endTime or a later now, should be able
+	// to complete.
+	if queryEnd.Equal(bucketEnd) {
+		if err := writeCacheFile(path, prices); err != nil {
+			return nil, err
+		}
+	}
+
+	return prices, nil
+}
+
+// cachePath returns the path that a given bucket's prices are cached at,
+// namespaced by provider and granularity so that different combinations
+// never collide on disk.
+func (c *cachedPriceBackend) cachePath(bucketStart time.Time) string {
+	fileName := fmt.Sprintf(
+		"%v_%v_%v.json", c.source, c.granularity,
+		bucketStart.Unix(),
+	)
+
+	return filepath.Join(c.cacheDir, fileName)
+}
+
+// cacheEntry is the on-disk representation of a single cached usd price.
+type cacheEntry struct {
+	Timestamp int64  `json:"timestamp"`
+	Price     string `json:"price"`
+}
+
+// readCacheFile reads and decodes a bucket's cache file from disk.
+func readCacheFile(path string) ([]*usdPrice, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []cacheEntry
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		return nil, err
+	}
+
+	prices := make([]*usdPrice, len(entries))
+	for i, entry := range entries {
+		price, err := decimal.NewFromString(entry.Price)
+		if err != nil {
+			return nil, err
+		}
+
+		prices[i] = &usdPrice{
+			timestamp: time.Unix(entry.Timestamp, 0),
+			price:     price,
+		}
+	}
+
+	return prices, nil
+}
+
+// writeCacheFile encodes and writes a bucket's prices to disk, creating the
+// cache directory if it does not already exist.
+func writeCacheFile(path string, prices []*usdPrice) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	entries := make([]cacheEntry, len(prices))
+	for i, price := range prices {
+		entries[i] = cacheEntry{
+			Timestamp: price.timestamp.Unix(),
+			Price:     price.price.String(),
+		}
+	}
+
+	raw, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, raw, 0600)
+}
@@ -0,0 +1,123 @@
+package fiat
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// countingBackend is a stub PriceBackend that records every range it is
+// queried for and returns a single canned price point per call.
+type countingBackend struct {
+	calls [][2]time.Time
+}
+
+func (c *countingBackend) GetPrices(_ context.Context, start,
+	end time.Time) ([]*usdPrice, error) {
+
+	c.calls = append(c.calls, [2]time.Time{start, end})
+
+	return []*usdPrice{{
+		timestamp: start,
+		price:     decimal.NewFromInt(100),
+	}}, nil
+}
+
+// TestCachedPriceBackendBucketing tests that cachedPriceBackend clips its
+// queries to buckets that have fully elapsed, and only persists a cache
+// file for a bucket once it has.
+func TestCachedPriceBackendBucketing(t *testing.T) {
+	tests := []struct {
+		name string
+
+		// startTime/endTime is the range requested of the cache.
+		startTime time.Time
+		endTime   time.Time
+
+		// expectCached is true if we expect a second identical
+		// request to be served entirely from disk, without the
+		// wrapped backend being queried again.
+		expectCached bool
+	}{
+		{
+			name:         "fully elapsed bucket is cached",
+			startTime:    time.Now().Add(-time.Hour * 48).Truncate(bucketSize),
+			endTime:      time.Now().Add(-time.Hour * 24).Truncate(bucketSize),
+			expectCached: true,
+		},
+		{
+			name:         "bucket covering today is not cached",
+			startTime:    time.Now().Truncate(bucketSize),
+			endTime:      time.Now().Add(time.Hour),
+			expectCached: false,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			cacheDir, err := ioutil.TempDir("", "fiat-cache-test")
+			if err != nil {
+				t.Fatalf("could not create temp dir: %v", err)
+			}
+			defer os.RemoveAll(cacheDir)
+
+			backend := &countingBackend{}
+			cached := NewCachedPriceBackend(
+				backend, CoinCapPriceSource, GranularityDay,
+				cacheDir,
+			)
+
+			if _, err := cached.GetPrices(
+				context.Background(), test.startTime, test.endTime,
+			); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			callsAfterFirst := len(backend.calls)
+
+			if _, err := cached.GetPrices(
+				context.Background(), test.startTime, test.endTime,
+			); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			calledAgain := len(backend.calls) > callsAfterFirst
+
+			if test.expectCached && calledAgain {
+				t.Fatalf("expected second request to be served " +
+					"from cache, but backend was queried again")
+			}
+
+			if !test.expectCached && !calledAgain {
+				t.Fatalf("expected second request to re-query " +
+					"the backend, but it was served from cache")
+			}
+
+			// The backend should never be asked for a range that
+			// extends beyond the caller's own endTime or the
+			// current time, even though buckets are day-aligned.
+			for _, call := range backend.calls {
+				queryEnd := call[1]
+
+				if queryEnd.After(test.endTime) {
+					t.Fatalf("queried backend for %v, "+
+						"beyond requested end time %v",
+						queryEnd, test.endTime)
+				}
+
+				if queryEnd.After(time.Now()) {
+					t.Fatalf("queried backend for %v, "+
+						"which is in the future", queryEnd)
+				}
+			}
+		})
+	}
+}
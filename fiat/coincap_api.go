@@ -107,6 +107,37 @@ type coinCapAPI struct {
 func (c *coinCapAPI) GetPrices(ctx context.Context, startTime,
 	endTime time.Time) ([]*usdPrice, error) {
 
+	return splitRequest(ctx, startTime, endTime, granularityLimits{
+		max: maxGranularityPeriod,
+		min: minGranularityPeriod,
+	}, c.granularity, c.query, c.convert)
+}
+
+// granularityLimits bundles the per-granularity min/max queryable period
+// tables that a fiat price backend imposes on a single request. Each provider
+// we support has its own limits, but the logic used to split a request up to
+// respect them is identical, so we share it via splitRequest below.
+type granularityLimits struct {
+	// max maps each granularity to the maximum period we are allowed to
+	// query for in a single request at that granularity.
+	max map[Granularity]time.Duration
+
+	// min maps each granularity to the minimum period we are allowed to
+	// query for in a single request at that granularity.
+	min map[Granularity]time.Duration
+}
+
+// splitRequest retrieves price information from a fiat price backend,
+// splitting the request for [startTime, endTime] into multiple requests sized
+// according to limits if required. This is shared between all of our
+// PriceBackend implementations because each provider restricts the period
+// that may be queried at a given granularity, differing only in their limits
+// and in how query/convert talk to the provider's api.
+func splitRequest(ctx context.Context, startTime, endTime time.Time,
+	limits granularityLimits, granularity Granularity,
+	query func(start, end time.Time, g Granularity) ([]byte, error),
+	convert func([]byte) ([]*usdPrice, error)) ([]*usdPrice, error) {
+
 	// First, check that we have a valid start and end time, and that the
 	// range specified is not in the future.
 	if err := utils.ValidateTimeRange(
@@ -119,15 +150,15 @@ func (c *coinCapAPI) GetPrices(ctx context.Context, startTime,
 	totalDuration := endTime.Sub(startTime).Seconds()
 
 	// Get the minimum period that we can query at this granularity.
-	min, ok := minGranularityPeriod[c.granularity]
+	min, ok := limits.min[granularity]
 	if !ok {
 		return nil, errUnknownGranularity
 	}
 
 	// If we are beneath minimum period, we shift our start time back by
 	// this minimum period. If we do not do this, we will not get any data
-	// from the coincap api. We shift start time backwards rather than end
-	// time forwards so that we do not accidentally query for times in
+	// from the backend's api. We shift start time backwards rather than
+	// end time forwards so that we do not accidentally query for times in
 	// the future.
 	if totalDuration < min.Seconds() {
 		startTime = startTime.Add(-1 * min)
@@ -136,7 +167,7 @@ func (c *coinCapAPI) GetPrices(ctx context.Context, startTime,
 
 	// Get maximum queryable period and ensure that we can obtain all the
 	// records within the limit we place on api calls.
-	max, ok := maxGranularityPeriod[c.granularity]
+	max, ok := limits.max[granularity]
 	if !ok {
 		return nil, errUnknownGranularity
 	}
@@ -160,13 +191,13 @@ func (c *coinCapAPI) GetPrices(ctx context.Context, startTime,
 			queryEnd = endTime
 		}
 
-		query := func() ([]byte, error) {
-			return c.query(queryStart, queryEnd, c.granularity)
+		queryFunc := func() ([]byte, error) {
+			return query(queryStart, queryEnd, granularity)
 		}
 
 		// Query the api for this page of data. We allow retries at this
 		// stage in case the api experiences a temporary limit.
-		records, err := retryQuery(ctx, query, c.convert)
+		records, err := retryQuery(ctx, queryFunc, convert)
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,130 @@
+package fiat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// coinGeckoAPIHost is the host of coingecko's api.
+	coinGeckoAPIHost = "https://api.coingecko.com/api/v3"
+
+	// coinGeckoMarketChartEndpoint is the endpoint we hit to get a range
+	// of historical market data for bitcoin, priced in usd.
+	coinGeckoMarketChartEndpoint = coinGeckoAPIHost +
+		"/coins/bitcoin/market_chart/range"
+)
+
+var (
+	// coinGeckoMaxGranularityPeriod holds the maximum period we may query
+	// for a given granularity from coingecko's api. Coingecko's range
+	// endpoint automatically adjusts the granularity of the data it
+	// returns based on the period requested (roughly: 5 minute data for
+	// ranges under a day, hourly data for ranges under 90 days, and daily
+	// data beyond that), so we cap our requests accordingly.
+	coinGeckoMaxGranularityPeriod = map[Granularity]time.Duration{
+		GranularityMinute:   time.Hour * 24,
+		Granularity5Minute:  time.Hour * 24,
+		Granularity15Minute: time.Hour * 24,
+		Granularity30Minute: time.Hour * 24,
+		GranularityHour:     time.Hour * 24 * 90,
+		Granularity6Hour:    time.Hour * 24 * 90,
+		Granularity12Hour:   time.Hour * 24 * 90,
+		GranularityDay:      time.Hour * 24 * 7305,
+	}
+
+	// coinGeckoMinGranularityPeriod holds the minimum period we may query
+	// for a given granularity from coingecko's api.
+	coinGeckoMinGranularityPeriod = map[Granularity]time.Duration{
+		GranularityMinute:   time.Minute,
+		Granularity5Minute:  time.Minute * 5,
+		Granularity15Minute: time.Minute * 15,
+		Granularity30Minute: time.Minute * 30,
+		GranularityHour:     time.Hour,
+		Granularity6Hour:    time.Hour * 6,
+		Granularity12Hour:   time.Hour * 12,
+		GranularityDay:      time.Hour * 24,
+	}
+)
+
+// coinGeckoAPI implements the PriceBackend interface, getting historical
+// Bitcoin prices from coingecko.
+type coinGeckoAPI struct {
+	// Coingecko's api returns data at a granularity that depends on the
+	// period queried, but we still record the granularity the caller
+	// asked for so that we can size our requests using splitRequest, the
+	// same way we do for coincap.
+	granularity Granularity
+
+	// query is the function that makes the http call out to coingecko's
+	// api. It is set within the struct so that it can be mocked for
+	// testing.
+	query func(start, end time.Time, g Granularity) ([]byte, error)
+
+	// convert produces usd prices from the output of the query function.
+	// It is set within the struct so that it can be mocked for testing.
+	convert func([]byte) ([]*usdPrice, error)
+}
+
+// GetPrices retrieves price information from coingecko's api, splitting the
+// request into multiple calls if required by coingecko's granularity/period
+// limits.
+func (c *coinGeckoAPI) GetPrices(ctx context.Context, startTime,
+	endTime time.Time) ([]*usdPrice, error) {
+
+	return splitRequest(ctx, startTime, endTime, granularityLimits{
+		max: coinGeckoMaxGranularityPeriod,
+		min: coinGeckoMinGranularityPeriod,
+	}, c.granularity, c.query, c.convert)
+}
+
+// queryCoinGecko calls coingecko's market chart range endpoint for the
+// period [start, end]. The granularity parameter is unused by coingecko's
+// api (it is implied by the period requested), but is kept in the query
+// signature so that coinGeckoAPI satisfies the same shape as our other
+// backends.
+func queryCoinGecko(start, end time.Time, _ Granularity) ([]byte, error) {
+	url := fmt.Sprintf("%v?vs_currency=usd&from=%v&to=%v",
+		coinGeckoMarketChartEndpoint, start.Unix(), end.Unix())
+
+	// nolint: gosec
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return ioutil.ReadAll(resp.Body)
+}
+
+// coinGeckoMarketChart mirrors the response body returned by coingecko's
+// market chart range endpoint.
+type coinGeckoMarketChart struct {
+	// Prices holds [timestamp_ms, price] pairs.
+	Prices [][2]float64 `json:"prices"`
+}
+
+// parseCoinGeckoData parses the bytes returned by coingecko's api into a set
+// of usd prices.
+func parseCoinGeckoData(data []byte) ([]*usdPrice, error) {
+	var chart coinGeckoMarketChart
+	if err := json.Unmarshal(data, &chart); err != nil {
+		return nil, err
+	}
+
+	prices := make([]*usdPrice, len(chart.Prices))
+	for i, entry := range chart.Prices {
+		prices[i] = &usdPrice{
+			timestamp: time.Unix(0, int64(entry[0])*int64(time.Millisecond)),
+			price:     decimal.NewFromFloat(entry[1]),
+		}
+	}
+
+	return prices, nil
+}
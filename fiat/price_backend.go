@@ -0,0 +1,91 @@
+package fiat
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// PriceBackend is the interface that must be implemented by a historical
+// fiat price source. It matches the shape that coinCapAPI has always
+// exposed, so that any of our supported providers can be used interchangeably
+// wherever we previously assumed coincap was our only source of prices.
+type PriceBackend interface {
+	// GetPrices returns a set of historical usd prices for the period
+	// [startTime, endTime].
+	GetPrices(ctx context.Context, startTime, endTime time.Time) ([]*usdPrice, error)
+}
+
+// PriceSource represents a fiat price backend that faraday knows how to
+// source historical BTC/USD prices from. NewPriceBackend maps it to the
+// provider's implementation; it is not exposed through faraday's CLI or
+// RPC.
+type PriceSource uint8
+
+const (
+	// CoinCapPriceSource obtains historical prices from coincap's api.
+	// This is faraday's original, default price source.
+	CoinCapPriceSource PriceSource = iota
+
+	// CoinGeckoPriceSource obtains historical prices from coingecko's
+	// api.
+	CoinGeckoPriceSource
+
+	// BitstampPriceSource obtains historical prices from bitstamp's OHLC
+	// api.
+	BitstampPriceSource
+)
+
+// String returns the human readable name of a price source.
+func (p PriceSource) String() string {
+	switch p {
+	case CoinCapPriceSource:
+		return "coincap"
+
+	case CoinGeckoPriceSource:
+		return "coingecko"
+
+	case BitstampPriceSource:
+		return "bitstamp"
+
+	default:
+		return "unknown"
+	}
+}
+
+// errUnknownPriceSource is returned when a caller requests a PriceBackend for
+// a PriceSource that we do not recognize.
+var errUnknownPriceSource = fmt.Errorf("unknown price source")
+
+// NewPriceBackend returns the concrete PriceBackend implementation associated
+// with the given PriceSource, configured to query the provider's live api at
+// the granularity provided.
+func NewPriceBackend(source PriceSource, granularity Granularity) (PriceBackend,
+	error) {
+
+	switch source {
+	case CoinCapPriceSource:
+		return &coinCapAPI{
+			granularity: granularity,
+			query:       queryCoinCap,
+			convert:     parseCoinCapData,
+		}, nil
+
+	case CoinGeckoPriceSource:
+		return &coinGeckoAPI{
+			granularity: granularity,
+			query:       queryCoinGecko,
+			convert:     parseCoinGeckoData,
+		}, nil
+
+	case BitstampPriceSource:
+		return &bitstampAPI{
+			granularity: granularity,
+			query:       queryBitstamp,
+			convert:     parseBitstampData,
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("%w: %v", errUnknownPriceSource, source)
+	}
+}
@@ -0,0 +1,285 @@
+// Package insights provides operators with a per-channel view of channel
+// health and profitability, combining data lnd exposes about a channel's
+// confirmation status and uptime with the revenue that channel has earned.
+package insights
+
+import (
+	"time"
+
+	"github.com/lightninglabs/faraday/revenue"
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/lnwire"
+)
+
+// Config provides the functionality required to produce channel insights.
+type Config struct {
+	// OpenChannels returns the set of channels that our node currently
+	// has open.
+	OpenChannels func() ([]lndclient.ChannelInfo, error)
+
+	// CurrentHeight returns our node's current block height, used to
+	// calculate the number of confirmations a channel's funding
+	// transaction has.
+	CurrentHeight func() (uint32, error)
+
+	// RevenueReport is a revenue report covering our currently open
+	// channels, used to attribute incoming/outgoing volume and fees to
+	// each one.
+	RevenueReport *revenue.Report
+
+	// HTLCFailures returns the htlc failures that lnd has recorded for
+	// the channel identified by chanPoint. It is optional; when nil, the
+	// HTLCFailureCount and HTLCFailuresByReason produced for every channel
+	// will be empty.
+	HTLCFailures func(chanPoint string) ([]HTLCFailure, error)
+
+	// FeeHistory returns the history of fee rate and enabled/disabled
+	// updates that lnd has recorded for the channel identified by
+	// chanPoint. It is optional; when nil, the FeeRateHistory produced
+	// for every channel will be empty.
+	FeeHistory func(chanPoint string) ([]FeeRateUpdate, error)
+
+	// LivenessHistory returns the liveness periods that lnd has recorded
+	// for the peer of the channel identified by chanPoint, broken down
+	// into individual online/offline spans rather than a single
+	// aggregate uptime duration. It is optional; when nil, the
+	// LivenessHistory produced for every channel will be empty.
+	LivenessHistory func(chanPoint string) ([]LivenessPeriod, error)
+}
+
+// HTLCFailure records a single htlc that failed while being forwarded over a
+// channel.
+type HTLCFailure struct {
+	// Timestamp is the time that the failure occurred.
+	Timestamp time.Time
+
+	// Reason describes why the htlc failed, as reported by lnd's htlc
+	// events subscription (for example: "insufficient balance" or
+	// "expiry too soon").
+	Reason string
+}
+
+// FeeRateUpdate records a single change to a channel's advertised routing
+// policy.
+type FeeRateUpdate struct {
+	// Timestamp is the time that the policy change took effect.
+	Timestamp time.Time
+
+	// BaseFeeMsat is the base fee advertised as of this update.
+	BaseFeeMsat lnwire.MilliSatoshi
+
+	// FeeRatePPM is the proportional fee rate, in parts per million,
+	// advertised as of this update.
+	FeeRatePPM int64
+
+	// Disabled indicates whether the channel was advertised as disabled
+	// as of this update.
+	Disabled bool
+}
+
+// LivenessPeriod records a single span of time that a channel's peer was
+// observed to be online or offline.
+type LivenessPeriod struct {
+	// Start is the beginning of the period.
+	Start time.Time
+
+	// End is the end of the period.
+	End time.Time
+
+	// Online indicates whether the peer was online for this period.
+	Online bool
+}
+
+// ChannelInfo summarizes a single channel's operational and financial
+// profile: how long it has been monitored and confirmed for, the volume and
+// fees it has earned, how reliably it has forwarded htlcs, how its fee
+// policy has evolved, and its peer's liveness over time. Together these let
+// an operator identify flaky peers and unprofitable fee schedules.
+type ChannelInfo struct {
+	// ChannelPoint is the funding transaction outpoint of the channel.
+	ChannelPoint string
+
+	// MonitoredFor is the period of time that we have been tracking this
+	// channel's uptime for.
+	MonitoredFor time.Duration
+
+	// Uptime is the amount of time that the remote peer of this channel
+	// has been online, out of MonitoredFor. It is retained as a single
+	// aggregate duration for backwards compatibility; LivenessHistory
+	// below provides the same information broken down into individual
+	// periods.
+	Uptime time.Duration
+
+	// Confirmations is the number of confirmations that this channel's
+	// funding transaction has, based on its short channel ID and our
+	// node's current height.
+	Confirmations uint32
+
+	// Private indicates whether this channel has been announced to the
+	// network.
+	Private bool
+
+	// VolumeIncoming is the amount that has arrived on this channel as
+	// the incoming leg of a forward.
+	VolumeIncoming lnwire.MilliSatoshi
+
+	// VolumeOutgoing is the amount that has left on this channel as the
+	// outgoing leg of a forward.
+	VolumeOutgoing lnwire.MilliSatoshi
+
+	// FeesEarned is the net fee revenue this channel has generated.
+	FeesEarned lnwire.MilliSatoshi
+
+	// HTLCFailureCount is the total number of htlc failures observed on
+	// this channel.
+	HTLCFailureCount int
+
+	// HTLCFailuresByReason breaks HTLCFailureCount down by failure
+	// reason, so that operators can distinguish, for example, a peer
+	// that is chronically out of balance from one that is unreachable.
+	HTLCFailuresByReason map[string]int
+
+	// FeeRateHistory is the history of fee rate and enabled/disabled
+	// updates we have advertised for this channel.
+	FeeRateHistory []FeeRateUpdate
+
+	// LivenessHistory is the peer's uptime broken down into individual
+	// online/offline periods, rather than the single aggregate Uptime
+	// duration above.
+	LivenessHistory []LivenessPeriod
+}
+
+// GetChannels builds a ChannelInfo for every one of our currently open
+// channels.
+func GetChannels(cfg *Config) ([]*ChannelInfo, error) {
+	channels, err := cfg.OpenChannels()
+	if err != nil {
+		return nil, err
+	}
+
+	currentHeight, err := cfg.CurrentHeight()
+	if err != nil {
+		return nil, err
+	}
+
+	insights := make([]*ChannelInfo, 0, len(channels))
+	for _, channel := range channels {
+		insight := &ChannelInfo{
+			ChannelPoint: channel.ChannelPoint,
+			MonitoredFor: channel.LifeTime,
+			Uptime:       channel.Uptime,
+			Confirmations: confirmations(
+				channel.ChannelID, currentHeight,
+			),
+			Private: channel.Private,
+		}
+
+		addRevenue(insight, cfg.RevenueReport)
+
+		if err := addHTLCFailures(insight, cfg); err != nil {
+			return nil, err
+		}
+
+		if err := addFeeHistory(insight, cfg); err != nil {
+			return nil, err
+		}
+
+		if err := addLivenessHistory(insight, cfg); err != nil {
+			return nil, err
+		}
+
+		insights = append(insights, insight)
+	}
+
+	return insights, nil
+}
+
+// confirmations returns the number of confirmations a channel's funding
+// transaction has, calculated from the block height encoded in its short
+// channel ID and our node's current height.
+func confirmations(chanID uint64, currentHeight uint32) uint32 {
+	scid := lnwire.NewShortChanIDFromInt(chanID)
+
+	return currentHeight - scid.BlockHeight + 1
+}
+
+// addRevenue attributes incoming/outgoing volume and net fees earned to
+// insight, based on the revenue report's entries for its channel point. A
+// channel's net fees earned nets out the portion of fees attributed to the
+// channels it was paired with as the outgoing leg of a forward, so that only
+// its own contribution is reflected.
+func addRevenue(insight *ChannelInfo, report *revenue.Report) {
+	if report == nil {
+		return
+	}
+
+	pairs, ok := report.ChannelPairs[insight.ChannelPoint]
+	if !ok {
+		return
+	}
+
+	for _, rev := range pairs {
+		insight.VolumeIncoming += rev.AmountIncoming
+		insight.VolumeOutgoing += rev.AmountOutgoing
+		insight.FeesEarned += rev.FeesIncoming - rev.FeesOutgoing
+	}
+}
+
+// addHTLCFailures populates insight's htlc failure fields using cfg's
+// optional HTLCFailures hook.
+func addHTLCFailures(insight *ChannelInfo, cfg *Config) error {
+	if cfg.HTLCFailures == nil {
+		return nil
+	}
+
+	failures, err := cfg.HTLCFailures(insight.ChannelPoint)
+	if err != nil {
+		return err
+	}
+
+	insight.HTLCFailureCount = len(failures)
+	if len(failures) == 0 {
+		return nil
+	}
+
+	insight.HTLCFailuresByReason = make(map[string]int, len(failures))
+	for _, failure := range failures {
+		insight.HTLCFailuresByReason[failure.Reason]++
+	}
+
+	return nil
+}
+
+// addFeeHistory populates insight's fee rate history using cfg's optional
+// FeeHistory hook.
+func addFeeHistory(insight *ChannelInfo, cfg *Config) error {
+	if cfg.FeeHistory == nil {
+		return nil
+	}
+
+	history, err := cfg.FeeHistory(insight.ChannelPoint)
+	if err != nil {
+		return err
+	}
+
+	insight.FeeRateHistory = history
+
+	return nil
+}
+
+// addLivenessHistory populates insight's per-peer liveness history using
+// cfg's optional LivenessHistory hook.
+func addLivenessHistory(insight *ChannelInfo, cfg *Config) error {
+	if cfg.LivenessHistory == nil {
+		return nil
+	}
+
+	history, err := cfg.LivenessHistory(insight.ChannelPoint)
+	if err != nil {
+		return err
+	}
+
+	insight.LivenessHistory = history
+
+	return nil
+}
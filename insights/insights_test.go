@@ -146,3 +146,88 @@ func TestGetChannels(t *testing.T) {
 		})
 	}
 }
+
+// TestGetChannelsOptionalHooks tests that the htlc failure, fee history and
+// liveness history hooks on Config are optional, and that their output is
+// aggregated onto ChannelInfo when provided.
+func TestGetChannelsOptionalHooks(t *testing.T) {
+	channel := lndclient.ChannelInfo{
+		ChannelPoint: "a:1",
+	}
+
+	noRevenue := &revenue.Report{
+		ChannelPairs: map[string]map[string]revenue.Revenue{},
+	}
+
+	baseCfg := Config{
+		OpenChannels: func() ([]lndclient.ChannelInfo, error) {
+			return []lndclient.ChannelInfo{channel}, nil
+		},
+		CurrentHeight: func() (uint32, error) {
+			return 0, nil
+		},
+		RevenueReport: noRevenue,
+	}
+
+	t.Run("hooks unset leave new fields empty", func(t *testing.T) {
+		insights, err := GetChannels(&baseCfg)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		insight := insights[0]
+		if insight.HTLCFailureCount != 0 || insight.FeeRateHistory != nil ||
+			insight.LivenessHistory != nil {
+
+			t.Fatalf("expected no optional data, got: %+v", insight)
+		}
+	})
+
+	t.Run("hooks set populate new fields", func(t *testing.T) {
+		cfg := baseCfg
+
+		cfg.HTLCFailures = func(string) ([]HTLCFailure, error) {
+			return []HTLCFailure{
+				{Reason: "insufficient balance"},
+				{Reason: "insufficient balance"},
+				{Reason: "expiry too soon"},
+			}, nil
+		}
+
+		cfg.FeeHistory = func(string) ([]FeeRateUpdate, error) {
+			return []FeeRateUpdate{{FeeRatePPM: 100}}, nil
+		}
+
+		cfg.LivenessHistory = func(string) ([]LivenessPeriod, error) {
+			return []LivenessPeriod{{Online: true}}, nil
+		}
+
+		insights, err := GetChannels(&cfg)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		insight := insights[0]
+
+		if insight.HTLCFailureCount != 3 {
+			t.Errorf("expected 3 htlc failures, got: %v",
+				insight.HTLCFailureCount)
+		}
+
+		if insight.HTLCFailuresByReason["insufficient balance"] != 2 {
+			t.Errorf("expected 2 insufficient balance failures, "+
+				"got: %v",
+				insight.HTLCFailuresByReason["insufficient balance"])
+		}
+
+		if len(insight.FeeRateHistory) != 1 {
+			t.Errorf("expected 1 fee rate update, got: %v",
+				len(insight.FeeRateHistory))
+		}
+
+		if len(insight.LivenessHistory) != 1 {
+			t.Errorf("expected 1 liveness period, got: %v",
+				len(insight.LivenessHistory))
+		}
+	})
+}